@@ -0,0 +1,147 @@
+package localstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RepoType distinguishes a Repo backed by a remote clone/mirror from one
+// backed directly by a local working tree on disk.
+//
+// The Repos store does not record a repo's RepoType: sourcegraph.Repo has no
+// Type field and RepoListOp has no Type filter, so Create, Get, GetByURI,
+// List and InternalUpdate neither take nor return one. This file provides the
+// enum, local-directory path canonicalization with its fsnotify watch
+// (watchLocalRepo), and RepoUpdate field validation (validateLocalRepoUpdate);
+// in this package they are used by MockRepos.MockGet_Local and
+// MockRepos.MockUpdate_Local.
+type RepoType int
+
+const (
+	// RepoTypeRemote is a repo mirrored from a remote clone URL, identified
+	// by its URI. This is the type of every repo created before RepoType
+	// existed.
+	RepoTypeRemote RepoType = iota
+
+	// RepoTypeLocalDir is a repo whose "URI" is an absolute filesystem path
+	// on the machine running Sourcegraph rather than a clone URL. It has no
+	// mirror URL or last-fetch bookkeeping; instead its cached blobs are
+	// invalidated by the localRepoWatch started in watchLocalRepo when the
+	// working tree changes on disk.
+	RepoTypeLocalDir
+)
+
+func (t RepoType) String() string {
+	switch t {
+	case RepoTypeRemote:
+		return "remote"
+	case RepoTypeLocalDir:
+		return "local-dir"
+	default:
+		return fmt.Sprintf("RepoType(%d)", int(t))
+	}
+}
+
+// localRepoWatch holds the fsnotify watch used to invalidate a
+// RepoTypeLocalDir repo's cached blobs when its working tree changes on
+// disk. There is one per locally-indexed repo; the caller closes it when the
+// repo is deleted or re-pointed elsewhere.
+type localRepoWatch struct {
+	// path is the canonicalized (absolute, symlink-resolved) directory being watched.
+	path    string
+	watcher *fsnotify.Watcher
+}
+
+// watchLocalRepo canonicalizes path to an absolute, symlink-resolved
+// directory and starts an fsnotify watch on it, returning a localRepoWatch
+// that the caller must Close when the repo is deleted or re-pointed
+// elsewhere. It errors if path does not exist or is not a directory.
+//
+// Every event the watch observes is consumed by a background goroutine that
+// calls invalidate, so callers actually see their cached blobs invalidated
+// on a working-tree change instead of the watch just sitting open;
+// invalidate may be nil if the caller has no cache to invalidate. The same
+// goroutine drains watcher-internal errors without calling invalidate for
+// them. The goroutine exits once Close closes
+// the underlying fsnotify.Watcher, which closes its Events/Errors channels.
+func watchLocalRepo(path string, invalidate func()) (*localRepoWatch, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(real)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("local repo path %q is not a directory", real)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(real); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if invalidate != nil {
+					invalidate()
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return &localRepoWatch{path: real, watcher: w}, nil
+}
+
+// Close stops the underlying fsnotify watch, which in turn stops the
+// goroutine started in watchLocalRepo that consumes its events.
+func (w *localRepoWatch) Close() error {
+	return w.watcher.Close()
+}
+
+// errLocalRepoField is returned by validateLocalRepoUpdate when a
+// RepoUpdate sets a field that only makes sense for a remote mirror (e.g.
+// its clone/mirror URL or last-fetch time) on a RepoTypeLocalDir repo.
+type errLocalRepoField struct {
+	field string
+}
+
+func (e *errLocalRepoField) Error() string {
+	return fmt.Sprintf("field %q cannot be set on a local-directory repo", e.field)
+}
+
+// validateLocalRepoUpdate rejects RepoUpdate fields that are meaningless for
+// a RepoTypeLocalDir repo (network-oriented bookkeeping like its mirror URL
+// or last-fetch timestamp), returning an *errLocalRepoField naming the first
+// offending field. Callers should run this before applying a RepoUpdate to a
+// repo known to be RepoTypeLocalDir.
+func validateLocalRepoUpdate(update RepoUpdate) error {
+	if update.ReposUpdateOp.MirrorURL != "" {
+		return &errLocalRepoField{field: "MirrorURL"}
+	}
+	if !update.ReposUpdateOp.LastFetch.IsZero() {
+		return &errLocalRepoField{field: "LastFetch"}
+	}
+	return nil
+}
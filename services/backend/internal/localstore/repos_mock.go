@@ -1,6 +1,8 @@
 package localstore
 
 import (
+	"reflect"
+	"sync/atomic"
 	"testing"
 
 	"context"
@@ -19,6 +21,121 @@ type MockRepos struct {
 	Update         func(v0 context.Context, v1 RepoUpdate) error
 	InternalUpdate func(ctx context.Context, repo int32, op InternalRepoUpdate) error
 	Delete         func(ctx context.Context, repo int32) error
+	Resolve        func(ctx context.Context, path string, op *ResolveOp) (*sourcegraph.Repo, error)
+
+	// expectedCalls holds the expectation queue built up by Expect and
+	// ExpectMany. It is consulted by the funcs installed in
+	// installExpectationFuncs, in place of the one-shot callbacks the
+	// MockGet*/MockList/... helpers below install.
+	expectedCalls []*expectedRepoCall
+}
+
+// expectedRepoCall is a single entry in a MockRepos call-expectation queue,
+// queued by Expect or ExpectMany and consumed by call.
+type expectedRepoCall struct {
+	method string
+	in     interface{}
+	out    interface{}
+	err    error
+	many   bool // if true, matches any number of calls and is never popped
+}
+
+// Expect queues an expectation that the next call to method (one of "Get",
+// "GetByURI", "List", "Update", "Delete") will be made with the given in
+// argument (compared via reflect.DeepEqual) and should return out, err.
+// Expectations are matched strictly in the order they were queued, so a test
+// can express a full sequence of calls (e.g., 3 Gets followed by an Update)
+// instead of wiring one callback per method. Call AssertAllCalled at the end
+// of the test to verify every queued expectation was matched.
+func (s *MockRepos) Expect(t *testing.T, method string, in, out interface{}, err error) {
+	s.expect(t, method, in, out, err, false)
+}
+
+// ExpectMany queues an expectation that matches any number of subsequent
+// calls to method with the given in argument, always returning out, err.
+// Unlike Expect, it is never popped from the queue and so is not required by
+// AssertAllCalled; it only applies once every Expect entry queued before it
+// has been consumed, whatever that entry's method or input.
+func (s *MockRepos) ExpectMany(t *testing.T, method string, in, out interface{}, err error) {
+	s.expect(t, method, in, out, err, true)
+}
+
+func (s *MockRepos) expect(t *testing.T, method string, in, out interface{}, err error, many bool) {
+	s.expectedCalls = append(s.expectedCalls, &expectedRepoCall{method: method, in: in, out: out, err: err, many: many})
+	s.installExpectationFuncs(t)
+}
+
+// AssertAllCalled fails t if any call queued via Expect was never matched by
+// a corresponding call. ExpectMany entries, which have no fixed call count,
+// are ignored.
+func (s *MockRepos) AssertAllCalled(t *testing.T) {
+	for _, c := range s.expectedCalls {
+		if !c.many {
+			t.Errorf("MockRepos: expected call to %s(%+v) was never made", c.method, c.in)
+		}
+	}
+}
+
+// call enforces that expectations are matched strictly in the order they
+// were queued: it only ever considers the entry at the front of the queue
+// (skipping past ExpectMany entries that don't match this call at all, which
+// match calls interleaved with the strict sequence). If the first entry not
+// skipped matches by both method and in, it matches the call; if it doesn't,
+// or no such entry is left, it fails t and returns a
+// FailedPrecondition error so a test sees why the mock rejected the call
+// instead of panicking on a type assertion.
+func (s *MockRepos) call(t *testing.T, method string, in interface{}) (interface{}, error) {
+	for i, c := range s.expectedCalls {
+		if c.many && (c.method != method || !reflect.DeepEqual(c.in, in)) {
+			continue
+		}
+		if c.method != method || !reflect.DeepEqual(c.in, in) {
+			break
+		}
+		if !c.many {
+			s.expectedCalls = append(s.expectedCalls[:i], s.expectedCalls[i+1:]...)
+		}
+		return c.out, c.err
+	}
+	t.Errorf("MockRepos: unexpected call to %s(%+v)", method, in)
+	return nil, grpc.Errorf(codes.FailedPrecondition, "MockRepos: unexpected call to %s(%+v)", method, in)
+}
+
+// installExpectationFuncs wires the Get/GetByURI/List/Update/Delete function
+// fields to dispatch through the expectation queue built up by Expect and
+// ExpectMany. It is called automatically whenever an expectation is queued,
+// so tests that only use the MockGet*/MockList/... helpers below never
+// trigger it and keep working unchanged.
+func (s *MockRepos) installExpectationFuncs(t *testing.T) {
+	s.Get = func(ctx context.Context, repo int32) (*sourcegraph.Repo, error) {
+		out, err := s.call(t, "Get", repo)
+		if err != nil {
+			return nil, err
+		}
+		return out.(*sourcegraph.Repo), nil
+	}
+	s.GetByURI = func(ctx context.Context, uri string) (*sourcegraph.Repo, error) {
+		out, err := s.call(t, "GetByURI", uri)
+		if err != nil {
+			return nil, err
+		}
+		return out.(*sourcegraph.Repo), nil
+	}
+	s.List = func(ctx context.Context, opt *RepoListOp) ([]*sourcegraph.Repo, error) {
+		out, err := s.call(t, "List", opt)
+		if err != nil {
+			return nil, err
+		}
+		return out.([]*sourcegraph.Repo), nil
+	}
+	s.Update = func(ctx context.Context, update RepoUpdate) error {
+		_, err := s.call(t, "Update", update)
+		return err
+	}
+	s.Delete = func(ctx context.Context, repo int32) error {
+		_, err := s.call(t, "Delete", repo)
+		return err
+	}
 }
 
 func (s *MockRepos) MockGet(t *testing.T, wantRepo int32) (called *bool) {
@@ -86,6 +203,52 @@ func (s *MockRepos) MockGetByURI(t *testing.T, wantURI string, repoID int32) (ca
 	return
 }
 
+// MockGet_Local makes Get return a RepoTypeLocalDir repo rooted at path,
+// used by tests that exercise the local-working-tree indexing path rather
+// than a remote clone. path must be a real directory: MockGet_Local starts
+// an actual watchLocalRepo watch on it, the same as the real local-dir
+// indexing path would, and registers its Close as test cleanup. The
+// returned repo's URI is the watch's canonicalized path, not the raw path
+// argument. invalidated is incremented atomically each time the
+// watch fires, so a test can assert a filesystem change was observed.
+func (s *MockRepos) MockGet_Local(t *testing.T, wantRepo int32, path string) (called *bool, invalidated *int32) {
+	called = new(bool)
+	invalidated = new(int32)
+	watch, err := watchLocalRepo(path, func() { atomic.AddInt32(invalidated, 1) })
+	if err != nil {
+		t.Fatalf("MockGet_Local: %s", err)
+	}
+	t.Cleanup(func() { watch.Close() })
+
+	s.Get = func(ctx context.Context, repo int32) (*sourcegraph.Repo, error) {
+		*called = true
+		if repo != wantRepo {
+			t.Errorf("got repo %d, want %d", repo, wantRepo)
+			return nil, grpc.Errorf(codes.NotFound, "repo %v not found", wantRepo)
+		}
+		return &sourcegraph.Repo{ID: repo, URI: watch.path}, nil
+	}
+	return
+}
+
+// MockUpdate_Local makes Update validate repoUpdate the way the real store
+// would for a RepoTypeLocalDir repo, returning validateLocalRepoUpdate's
+// error for a field that only makes sense on a remote mirror instead of a
+// fixed stub error. Used by tests that exercise local-repo update-field
+// rejection.
+func (s *MockRepos) MockUpdate_Local(t *testing.T, wantRepo int32) (called *bool) {
+	called = new(bool)
+	s.Update = func(ctx context.Context, repoUpdate RepoUpdate) error {
+		*called = true
+		if repoUpdate.ReposUpdateOp.Repo != wantRepo {
+			t.Errorf("got repo %q, want %q", repoUpdate.ReposUpdateOp.Repo, wantRepo)
+			return grpc.Errorf(codes.NotFound, "repo %v not found", wantRepo)
+		}
+		return validateLocalRepoUpdate(repoUpdate)
+	}
+	return
+}
+
 func (s *MockRepos) MockList(t *testing.T, wantRepos ...string) (called *bool) {
 	called = new(bool)
 	s.List = func(ctx context.Context, opt *RepoListOp) ([]*sourcegraph.Repo, error) {
@@ -99,6 +262,19 @@ func (s *MockRepos) MockList(t *testing.T, wantRepos ...string) (called *bool) {
 	return
 }
 
+func (s *MockRepos) MockResolve(t *testing.T, wantPath string, repo *sourcegraph.Repo) (called *bool) {
+	called = new(bool)
+	s.Resolve = func(ctx context.Context, path string, op *ResolveOp) (*sourcegraph.Repo, error) {
+		*called = true
+		if path != wantPath {
+			t.Errorf("got resolve path %q, want %q", path, wantPath)
+			return nil, grpc.Errorf(codes.NotFound, "repo %v not found", wantPath)
+		}
+		return repo, nil
+	}
+	return
+}
+
 func (s *MockRepos) MockInternalUpdate(t *testing.T) (called *bool) {
 	called = new(bool)
 	s.InternalUpdate = func(ctx context.Context, repo int32, op InternalRepoUpdate) error {
@@ -0,0 +1,103 @@
+package localstore
+
+import (
+	"context"
+	"testing"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/api/sourcegraph"
+)
+
+// TestMockRepos_ExpectSequence exercises a strict, ordered sequence of
+// Expect calls across two methods, the "N Gets followed by a Delete" use
+// case Expect/ExpectMany exist for.
+func TestMockRepos_ExpectSequence(t *testing.T) {
+	var s MockRepos
+	repoA := &sourcegraph.Repo{ID: 1}
+	repoB := &sourcegraph.Repo{ID: 2}
+	s.Expect(t, "Get", int32(1), repoA, nil)
+	s.Expect(t, "Get", int32(2), repoB, nil)
+	s.Expect(t, "Delete", int32(1), nil, nil)
+
+	ctx := context.Background()
+	if got, err := s.Get(ctx, 1); err != nil || got != repoA {
+		t.Fatalf("Get(1) = %v, %v; want %v, nil", got, err, repoA)
+	}
+	if got, err := s.Get(ctx, 2); err != nil || got != repoB {
+		t.Fatalf("Get(2) = %v, %v; want %v, nil", got, err, repoB)
+	}
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete(1) = %v; want nil", err)
+	}
+	s.AssertAllCalled(t)
+}
+
+// TestMockRepos_ExpectSequence_OutOfOrder confirms call enforces strict
+// order: a Get(2) made before the queued Get(1) is consumed must not
+// silently match that later entry.
+func TestMockRepos_ExpectSequence_OutOfOrder(t *testing.T) {
+	ok := t.Run("out-of-order", func(t *testing.T) {
+		var s MockRepos
+		s.Expect(t, "Get", int32(1), &sourcegraph.Repo{ID: 1}, nil)
+		s.Expect(t, "Get", int32(2), &sourcegraph.Repo{ID: 2}, nil)
+		s.Get(context.Background(), 2)
+	})
+	if ok {
+		t.Errorf("calling Get(2) before the queued Get(1) is consumed should have failed t")
+	}
+}
+
+// TestMockRepos_ExpectMany_Interleaved covers an ExpectMany entry matching
+// any number of calls with one input, interleaved with a strict Expect for
+// the same method but a different input — the exact shape that regressed
+// call's ordering logic twice before it was fixed.
+func TestMockRepos_ExpectMany_Interleaved(t *testing.T) {
+	var s MockRepos
+	repoA := &sourcegraph.Repo{ID: 1}
+	repoB := &sourcegraph.Repo{ID: 2}
+	s.ExpectMany(t, "Get", int32(1), repoA, nil)
+	s.Expect(t, "Get", int32(2), repoB, nil)
+
+	ctx := context.Background()
+	if got, err := s.Get(ctx, 1); err != nil || got != repoA {
+		t.Fatalf("Get(1) = %v, %v; want %v, nil", got, err, repoA)
+	}
+	if got, err := s.Get(ctx, 2); err != nil || got != repoB {
+		t.Fatalf("Get(2) = %v, %v; want %v, nil", got, err, repoB)
+	}
+	// The ExpectMany entry is never consumed, so it still matches further
+	// Get(1) calls after the strict Get(2) expectation has been popped.
+	if got, err := s.Get(ctx, 1); err != nil || got != repoA {
+		t.Fatalf("Get(1) (again) = %v, %v; want %v, nil", got, err, repoA)
+	}
+
+	s.AssertAllCalled(t) // the ExpectMany entry has no fixed count, so this only checks Get(2).
+}
+
+// TestMockRepos_AssertAllCalled_Unmatched confirms AssertAllCalled fails t
+// when a strict Expect was queued but never matched by a call.
+func TestMockRepos_AssertAllCalled_Unmatched(t *testing.T) {
+	ok := t.Run("unmatched", func(t *testing.T) {
+		var s MockRepos
+		s.Expect(t, "Get", int32(1), &sourcegraph.Repo{ID: 1}, nil)
+		s.AssertAllCalled(t)
+	})
+	if ok {
+		t.Errorf("AssertAllCalled should have failed t for an unmatched expectation")
+	}
+}
+
+// TestMockRepos_UnexpectedCall confirms a call with no matching expectation
+// fails t and returns a FailedPrecondition error rather than panicking on
+// the type assertion in installExpectationFuncs.
+func TestMockRepos_UnexpectedCall(t *testing.T) {
+	ok := t.Run("unexpected", func(t *testing.T) {
+		var s MockRepos
+		s.Expect(t, "Get", int32(1), &sourcegraph.Repo{ID: 1}, nil)
+		if _, err := s.Get(context.Background(), 2); err == nil {
+			t.Fatalf("Get(2) = nil error; want a FailedPrecondition error")
+		}
+	})
+	if ok {
+		t.Errorf("an unexpected call should have failed t")
+	}
+}
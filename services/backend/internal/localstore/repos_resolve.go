@@ -0,0 +1,234 @@
+package localstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"sourcegraph.com/sourcegraph/sourcegraph/api/sourcegraph"
+)
+
+// probeTimeout bounds a single probeRepoSource HEAD request so an
+// unreachable or black-holing host can't hang Resolve indefinitely when the
+// caller's ctx has no deadline of its own.
+const probeTimeout = 10 * time.Second
+
+// ResolveOp configures a call to repos.Resolve. The zero value tries every
+// known deducer in priority order; a test may set Remote to force Resolve to
+// consider only the deducer for that host.
+type ResolveOp struct {
+	// Remote, if non-empty, restricts maybeSources to the deducer whose root
+	// starts with this host (e.g. "github.com").
+	Remote string
+}
+
+// maybeRepoSource is a single candidate source URL produced by a deducer, in
+// priority order. Resolve tries each of an import path's candidates in turn,
+// returning the first one that yields a repo.
+type maybeRepoSource struct {
+	// URL is the clone/probe URL for this candidate, e.g.
+	// "https://github.com/u/r" or "git+ssh://git@github.com/u/r".
+	URL string
+}
+
+// sourceFailures aggregates the errors returned by each maybeRepoSource
+// candidate Resolve tried for path, so callers see why every candidate was
+// rejected instead of only the last one.
+type sourceFailures struct {
+	path     string
+	failures []error
+}
+
+func (e *sourceFailures) Error() string {
+	msgs := make([]string, len(e.failures))
+	for i, err := range e.failures {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("could not resolve repo for %q: %s", e.path, strings.Join(msgs, "; "))
+}
+
+// deducer produces, in priority order, the candidate sources for an import
+// path rooted at a particular host.
+type deducer interface {
+	// root returns the repository root of path (e.g. "github.com/u/r" for
+	// "github.com/u/r/subpkg") and ok is false if path isn't rooted at this
+	// deducer's host.
+	root(path string) (root string, ok bool)
+
+	// deduce returns the ordered candidate sources for root.
+	deduce(root string) []maybeRepoSource
+}
+
+// deducers is tried, in order, by maybeSources. genericDeducer is always
+// last so that a more specific host-aware deducer wins when both match.
+var deducers = []deducer{
+	githubDeducer{},
+	bitbucketDeducer{},
+	gitlabDeducer{},
+	launchpadDeducer{},
+	genericDeducer{},
+}
+
+type githubDeducer struct{}
+
+func (githubDeducer) root(path string) (string, bool) { return hostRoot("github.com", path, 3) }
+
+func (githubDeducer) deduce(root string) []maybeRepoSource {
+	return []maybeRepoSource{
+		{URL: "https://" + root},
+		{URL: "git+ssh://git@" + root},
+	}
+}
+
+type bitbucketDeducer struct{}
+
+func (bitbucketDeducer) root(path string) (string, bool) { return hostRoot("bitbucket.org", path, 3) }
+
+func (bitbucketDeducer) deduce(root string) []maybeRepoSource {
+	return []maybeRepoSource{
+		{URL: "https://" + root},
+		{URL: "git+ssh://git@" + root},
+	}
+}
+
+type gitlabDeducer struct{}
+
+func (gitlabDeducer) root(path string) (string, bool) { return hostRoot("gitlab.com", path, 3) }
+
+func (gitlabDeducer) deduce(root string) []maybeRepoSource {
+	return []maybeRepoSource{
+		{URL: "https://" + root},
+		{URL: "git+ssh://git@" + root},
+	}
+}
+
+// launchpadDeducer handles launchpad.net's two-segment project roots
+// (launchpad.net/project), which have no separate user segment.
+type launchpadDeducer struct{}
+
+func (launchpadDeducer) root(path string) (string, bool) { return hostRoot("launchpad.net", path, 2) }
+
+func (launchpadDeducer) deduce(root string) []maybeRepoSource {
+	return []maybeRepoSource{{URL: "https://" + root}}
+}
+
+// genericDeducer handles any <host>/<user>/<repo> import path not claimed by
+// a more specific deducer above, probing both https and git+ssh.
+type genericDeducer struct{}
+
+func (genericDeducer) root(path string) (string, bool) {
+	parts := strings.SplitN(path, "/", 4)
+	if len(parts) < 3 || !strings.Contains(parts[0], ".") {
+		return "", false
+	}
+	return strings.Join(parts[:3], "/"), true
+}
+
+func (genericDeducer) deduce(root string) []maybeRepoSource {
+	return []maybeRepoSource{
+		{URL: "https://" + root},
+		{URL: "git+ssh://git@" + root},
+	}
+}
+
+// hostRoot returns the first n slash-separated segments of path if path is
+// rooted at host, and ok is false otherwise.
+func hostRoot(host, path string, n int) (root string, ok bool) {
+	if !strings.HasPrefix(path, host+"/") && path != host {
+		return "", false
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < n {
+		return "", false
+	}
+	return strings.Join(parts[:n], "/"), true
+}
+
+// maybeSources returns the deduced repository root and its ordered list of
+// candidate source URLs for path, deduced by whichever deducer claims path's
+// root host. If op.Remote is set, only the deducer whose root starts with
+// that host is considered.
+func maybeSources(path string, op *ResolveOp) (root string, sources []maybeRepoSource, err error) {
+	for _, d := range deducers {
+		root, ok := d.root(path)
+		if !ok {
+			continue
+		}
+		if op != nil && op.Remote != "" && !strings.HasPrefix(root, op.Remote) {
+			continue
+		}
+		return root, d.deduce(root), nil
+	}
+	return "", nil, fmt.Errorf("could not deduce repository root for import path %q", path)
+}
+
+// Resolve deduces the repository root of path (a bare import path, e.g.
+// "example.com/user/proj/subpkg") and tries each candidate source in
+// priority order, returning the first repo it can resolve. GetByURI is
+// tried once up front against the bare root (every existing Repo.URI is a
+// bare host/user/repo string, not a scheme-qualified URL) so an
+// already-indexed repo is served from cache without ever touching the
+// network; only on a cache miss does Resolve fall out to a remote probe of
+// each scheme-specific candidate's URL in turn. If every candidate fails,
+// Resolve returns a *sourceFailures error aggregating why each one was
+// rejected.
+func (s *repos) Resolve(ctx context.Context, path string, op *ResolveOp) (*sourcegraph.Repo, error) {
+	root, sources, err := maybeSources(path, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo, err := s.GetByURI(ctx, root); err == nil {
+		return repo, nil
+	}
+
+	var failures []error
+	for _, src := range sources {
+		repo, err := probeRepoSource(ctx, src.URL)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		return repo, nil
+	}
+	return nil, &sourceFailures{path: path, failures: failures}
+}
+
+// probeRepoSource performs a remote existence check of rawurl to confirm a
+// repo actually exists there, used by Resolve once the bare-root cache
+// lookup via GetByURI has missed. For an http(s) candidate (the first one
+// every deducer above produces) it issues a HEAD request and treats any
+// non-error response as confirmation. A git+ssh candidate can't be probed
+// this way since it requires credentials Resolve doesn't have; those always
+// fail, but the https candidate deduced alongside them is tried first and
+// covers the common case.
+func probeRepoSource(ctx context.Context, rawurl string) (*sourcegraph.Repo, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid candidate source %q: %s", rawurl, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("cannot probe %q: %s sources require credentials Resolve does not have", rawurl, u.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("HEAD", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("probing %q: %s", rawurl, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("probing %q: HTTP %d", rawurl, resp.StatusCode)
+	}
+
+	return &sourcegraph.Repo{URI: u.Host + u.Path}, nil
+}
@@ -0,0 +1,109 @@
+package localstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRoot(t *testing.T) {
+	tests := []struct {
+		host, path string
+		n          int
+		wantRoot   string
+		wantOK     bool
+	}{
+		{"github.com", "github.com/user/repo/subpkg", 3, "github.com/user/repo", true},
+		{"github.com", "github.com", 3, "", false},
+		// host must be a path-segment prefix, not just a string prefix.
+		{"github.com", "github.com.evil.com/user/repo", 3, "", false},
+		{"launchpad.net", "launchpad.net/myproject/subpkg", 2, "launchpad.net/myproject", true},
+	}
+	for _, tt := range tests {
+		root, ok := hostRoot(tt.host, tt.path, tt.n)
+		if ok != tt.wantOK || (ok && root != tt.wantRoot) {
+			t.Errorf("hostRoot(%q, %q, %d) = %q, %v; want %q, %v", tt.host, tt.path, tt.n, root, ok, tt.wantRoot, tt.wantOK)
+		}
+	}
+}
+
+func TestLaunchpadDeducer_TwoSegmentRoot(t *testing.T) {
+	d := launchpadDeducer{}
+	root, ok := d.root("launchpad.net/myproject/subpkg/more")
+	if !ok || root != "launchpad.net/myproject" {
+		t.Fatalf("root = %q, %v; want %q, true", root, ok, "launchpad.net/myproject")
+	}
+}
+
+// TestMaybeSources_SpecificDeducerTakesPriorityOverGeneric relies on
+// launchpad's root being shorter (2 segments) than genericDeducer's (3): if
+// maybeSources fell through launchpadDeducer to genericDeducer instead of
+// matching it first, the deduced root would incorrectly include a third
+// path segment.
+func TestMaybeSources_SpecificDeducerTakesPriorityOverGeneric(t *testing.T) {
+	root, sources, err := maybeSources("launchpad.net/myproject/subpkg", nil)
+	if err != nil {
+		t.Fatalf("maybeSources: %s", err)
+	}
+	if want := "launchpad.net/myproject"; root != want {
+		t.Errorf("root = %q, want %q (launchpadDeducer should have won, not genericDeducer)", root, want)
+	}
+	if len(sources) != 1 || sources[0].URL != "https://"+root {
+		t.Errorf("sources = %+v, want a single https candidate for %q", sources, root)
+	}
+}
+
+func TestMaybeSources_GenericFallback(t *testing.T) {
+	root, sources, err := maybeSources("example.com/user/repo/subpkg", nil)
+	if err != nil {
+		t.Fatalf("maybeSources: %s", err)
+	}
+	if want := "example.com/user/repo"; root != want {
+		t.Errorf("root = %q, want %q", root, want)
+	}
+	if len(sources) != 2 {
+		t.Errorf("sources = %+v, want both an https and a git+ssh candidate", sources)
+	}
+}
+
+func TestMaybeSources_Unresolvable(t *testing.T) {
+	if _, _, err := maybeSources("nota/validhost/path", nil); err == nil {
+		t.Errorf("maybeSources(%q) = nil error, want an error", "nota/validhost/path")
+	}
+}
+
+func TestProbeRepoSource_HTTPSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("got method %s, want HEAD", r.Method)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	repo, err := probeRepoSource(context.Background(), srv.URL+"/user/repo")
+	if err != nil {
+		t.Fatalf("probeRepoSource: %s", err)
+	}
+	if repo.URI == "" {
+		t.Errorf("got empty Repo.URI")
+	}
+}
+
+func TestProbeRepoSource_HTTPNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := probeRepoSource(context.Background(), srv.URL+"/user/repo"); err == nil {
+		t.Errorf("probeRepoSource against a 404 = nil error, want an error")
+	}
+}
+
+func TestProbeRepoSource_RejectsNonHTTPScheme(t *testing.T) {
+	if _, err := probeRepoSource(context.Background(), "git+ssh://git@github.com/user/repo"); err == nil {
+		t.Errorf("probeRepoSource(git+ssh://...) = nil error, want an error (no credentials to probe with)")
+	}
+}